@@ -5,19 +5,33 @@
 package cache
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Cache is a simple LRU cache implementation for cacheable object creation.
+// Cache is a simple cache implementation for cacheable object creation. The
+// eviction policy used when MaxItems is exceeded is selected via
+// Config.Policy.
 type Cache[K comparable, V any] struct {
-	create     CreateFunc[K, V]
-	remove     RemoveFunc[K, V]
-	maxItems   int
-	maxAge     time.Duration
-	entries    map[K]*entry[K, V]
-	head, tail entry[K, V]
-	mu         sync.Mutex
+	create       CreateFunc[K, V]
+	createCtx    CreateFuncCtx[K, V]
+	remove       RemoveFunc[K, V]
+	observer     Observer[K]
+	codec        Codec[K, V]
+	maxItems     int
+	maxAge       time.Duration
+	refreshAhead time.Duration
+	policyKind   Policy
+	entries      map[K]*entry[K, V]
+	policy       evictionPolicy[K]
+	stats        statsCounters
+	mu           sync.Mutex
+
+	closeOnce   sync.Once
+	janitorStop chan struct{}
+	janitorDone chan struct{}
 }
 
 // entry is the container node that holds a cache entry.
@@ -25,11 +39,14 @@ type entry[K comparable, V any] struct {
 	key        K
 	val        V
 	deadline   time.Time // zero value for no deadline
-	prev, next *entry[K, V]
 	created    bool
 	deleted    bool
+	refreshing bool // a background refresh-ahead reload is in flight
 	err        error
-	cond       *sync.Cond
+	ready      chan struct{} // closed when created or deleted
+	waiters    int           // number of goroutines still interested in the result
+	cancel     context.CancelFunc
+	mu         sync.Mutex
 }
 
 // Config is the config parameer set which is passed to NewWithConfig.
@@ -38,6 +55,13 @@ type Config[K comparable, V any] struct {
 	// called when Get is called for a key that does not exist in the cache.
 	CreateFunc CreateFunc[K, V]
 
+	// CreateFuncCtx is like CreateFunc, but additionally receives a
+	// context.Context that is cancelled once no caller is waiting for the
+	// result anymore. See the CreateFuncCtx type. Set this instead of
+	// CreateFunc when the creation should be able to observe cancellation.
+	// Only one of CreateFunc or CreateFuncCtx should be set.
+	CreateFuncCtx CreateFuncCtx[K, V]
+
 	// RemoveFunc is the optional function that is called immediately after
 	// a cache entry is removed from the cache.
 	RemoveFunc RemoveFunc[K, V]
@@ -49,6 +73,32 @@ type Config[K comparable, V any] struct {
 	// MaxAge is the maximum time since an item was created and cached.
 	// 0 indicates unlimited.
 	MaxAge time.Duration
+
+	// Policy selects the eviction policy used when MaxItems is exceeded.
+	// The zero value, PolicyLRU, evicts the least recently used key.
+	Policy Policy
+
+	// Observer, if set, is notified of cache hits, misses, evictions,
+	// expirations, and creations. See the Observer type.
+	Observer Observer[K]
+
+	// CleanupInterval, if non-zero, starts a background goroutine that
+	// calls CheckAndExpire on this interval for as long as the Cache is in
+	// use. Call Close to stop it and release its resources.
+	CleanupInterval time.Duration
+
+	// RefreshAhead, if non-zero, enables stale-while-revalidate behavior:
+	// once an entry's remaining time to its deadline drops to RefreshAhead
+	// or below, Get still returns the cached value immediately, but also
+	// starts a background reload via CreateFunc/CreateFuncCtx to replace it
+	// before it hard-expires. Concurrent hits on the same key while a
+	// refresh is already running do not start another one.
+	RefreshAhead time.Duration
+
+	// Codec, if set, is used by Save and Load instead of the default
+	// encoding/gob-based format. Set this when K or V cannot be registered
+	// with encoding/gob.
+	Codec Codec[K, V]
 }
 
 // CreateFunc represents a function for object creation. It will be called when
@@ -57,6 +107,15 @@ type Config[K comparable, V any] struct {
 // when no deadline is specified.
 type CreateFunc[K comparable, V any] func(K) (V, time.Time, error)
 
+// CreateFuncCtx is the context-aware variant of CreateFunc. It receives a
+// context.Context of its own, which is cancelled once no caller is waiting
+// for the result anymore. It is not derived from any caller's context, so
+// the values and deadlines of the ctx passed to GetContext are not visible
+// through it. Background reloads started by RefreshAhead or Refresh receive
+// a context that is never cancelled. It should return promptly with
+// ctx.Err() when ctx is done.
+type CreateFuncCtx[K comparable, V any] func(ctx context.Context, key K) (V, time.Time, error)
+
 // RemoveFunc represents a function to be called when an item is removed from
 // the cache. It can be used to free resources such as files held by the value.
 // This is called inside locks, so it is recommended to return immediately.
@@ -79,16 +138,20 @@ func New[K comparable, V any](create CreateFunc[K, V]) *Cache[K, V] {
 // NewWithConfig creates a Cache with specified configuration.
 func NewWithConfig[K comparable, V any](conf *Config[K, V]) *Cache[K, V] {
 	c := &Cache[K, V]{
-		create:   conf.CreateFunc,
-		remove:   conf.RemoveFunc,
-		maxItems: conf.MaxItems,
-		maxAge:   conf.MaxAge,
-		entries:  make(map[K]*entry[K, V]),
+		create:       conf.CreateFunc,
+		createCtx:    conf.CreateFuncCtx,
+		remove:       conf.RemoveFunc,
+		observer:     conf.Observer,
+		codec:        conf.Codec,
+		maxItems:     conf.MaxItems,
+		maxAge:       conf.MaxAge,
+		refreshAhead: conf.RefreshAhead,
+		policyKind:   conf.Policy,
+		entries:      make(map[K]*entry[K, V]),
+		policy:       newPolicy[K](conf.Policy, conf.MaxItems),
 	}
-	c.head.next, c.tail.prev = &c.tail, &c.head
-	if conf != nil {
-		c.maxAge = conf.MaxAge
-		c.maxItems = conf.MaxItems
+	if conf.CleanupInterval > 0 {
+		c.startJanitor(conf.CleanupInterval)
 	}
 
 	return c
@@ -100,21 +163,43 @@ func NewWithConfig[K comparable, V any](conf *Config[K, V]) *Cache[K, V] {
 // If MaxAge or the second return value of CreateFunc is used, it is better to
 // call this method periodically to remove expired cache items.
 func (c *Cache[K, V]) CheckAndExpire() {
+	var expired []K
 	c.mu.Lock()
 	for key, item := range c.entries {
-		item.cond.L.Lock()
+		item.mu.Lock()
 		if !item.created || item.deleted || item.deadline.IsZero() || item.deadline.After(time.Now()) {
-			item.cond.L.Unlock()
+			item.mu.Unlock()
 			continue
 		}
-		item.prev.next, item.next.prev = item.next, item.prev
 		delete(c.entries, key)
+		c.policy.Remove(key)
 		item.deleted = true
 		if c.remove != nil {
 			c.remove(key, item.val)
 		}
+		item.mu.Unlock()
+		expired = append(expired, key)
+	}
+	c.mu.Unlock()
+
+	if len(expired) == 0 {
+		return
+	}
+	atomic.AddUint64(&c.stats.expirations, uint64(len(expired)))
+	if c.observer != nil {
+		for _, key := range expired {
+			c.observer.OnExpire(key)
+		}
 	}
+}
+
+// Stats returns a point-in-time snapshot of this Cache's activity counters.
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	size := len(c.entries)
 	c.mu.Unlock()
+
+	return c.stats.snapshot(size)
 }
 
 // Get gets the value for the key from the cache. If it does not exist in the
@@ -123,82 +208,157 @@ func (c *Cache[K, V]) CheckAndExpire() {
 // Note that the cache expiration time 0 represents that it never expired,
 // not uncachable.
 func (c *Cache[K, V]) Get(key K) (V, bool, time.Time, error) {
-	var wait bool
+	return c.GetContext(context.Background(), key)
+}
 
+// GetContext is like Get, but takes a context.Context. If ctx is cancelled
+// while this call is waiting for an in-flight creation to complete, it
+// returns immediately with ctx.Err() without cancelling the creation for
+// other callers waiting on the same key. If ctx is cancelled while this call
+// itself is responsible for the creation, the creation is handed off to
+// another waiter for the same key if one exists; otherwise it is cancelled.
+func (c *Cache[K, V]) GetContext(ctx context.Context, key K) (V, bool, time.Time, error) {
 	c.mu.Lock()
 	item, found := c.entries[key]
+	var expired bool
 	if found {
-		item.cond.L.Lock()
+		item.mu.Lock()
 		if item.created {
 			if item.deadline.IsZero() || item.deadline.After(time.Now()) {
-				if c.head.next != item {
-					item.prev.next, item.next.prev = item.next, item.prev
-					item.next, c.head.next.prev = c.head.next, item
-					c.head.next, item.prev = item, &c.head
+				c.policy.Touch(key)
+				val, deadline := item.val, item.deadline
+				needsRefresh := c.refreshAhead > 0 && !item.refreshing &&
+					!deadline.IsZero() && time.Until(deadline) <= c.refreshAhead
+				if needsRefresh {
+					item.refreshing = true
 				}
-				item.cond.L.Unlock()
+				item.mu.Unlock()
 				c.mu.Unlock()
 
-				return item.val, true, item.deadline, nil
+				atomic.AddUint64(&c.stats.hits, 1)
+				if c.observer != nil {
+					c.observer.OnHit(key)
+				}
+				if needsRefresh {
+					atomic.AddInt64(&c.stats.inFlight, 1)
+					go c.refresh(key, item)
+				}
+
+				return val, true, deadline, nil
 			}
 
 			// expired
-			item.prev.next, item.next.prev = item.next, item.prev
 			delete(c.entries, key)
+			c.policy.Remove(key)
 			item.deleted = true
 			if c.remove != nil {
 				c.remove(key, item.val)
 			}
 			found = false
-		} else {
-			wait = true
+			expired = true
 		}
-		item.cond.L.Unlock()
+		item.mu.Unlock()
 	}
-	if !found {
-		item = &entry[K, V]{
-			cond: sync.NewCond(&sync.Mutex{}),
-		}
-		c.entries[key] = item
-		if c.maxItems != 0 && c.maxItems < len(c.entries) {
-			last := c.tail.prev
-			last.prev.next, c.tail.prev = &c.tail, last.prev
-			delete(c.entries, last.key)
-			last.deleted = true
-			if c.remove != nil {
-				c.remove(last.key, last.val)
+	if found {
+		item.mu.Lock()
+		item.waiters++
+		item.mu.Unlock()
+		c.mu.Unlock()
+
+		return c.wait(ctx, item, false)
+	}
+
+	cctx, cancel := context.WithCancel(context.Background())
+	item = &entry[K, V]{
+		key:     key,
+		ready:   make(chan struct{}),
+		waiters: 1,
+		cancel:  cancel,
+	}
+	c.entries[key] = item
+	var evictedKey K
+	var evicted bool
+	if victim, evict := c.policy.Admit(key); evict {
+		if victimItem, ok := c.entries[victim]; ok {
+			delete(c.entries, victim)
+			victimItem.mu.Lock()
+			victimItem.deleted = true
+			wasCreated, val := victimItem.created, victimItem.val
+			victimItem.mu.Unlock()
+			if wasCreated && c.remove != nil {
+				c.remove(victim, val)
 			}
+			evictedKey, evicted = victim, wasCreated
 		}
 	}
 	c.mu.Unlock()
 
-	if wait {
-		item.cond.L.Lock()
-		for !item.created && !item.deleted {
-			item.cond.Wait()
+	if expired {
+		atomic.AddUint64(&c.stats.expirations, 1)
+		if c.observer != nil {
+			c.observer.OnExpire(key)
+		}
+	}
+	if evicted {
+		atomic.AddUint64(&c.stats.evictions, 1)
+		if c.observer != nil {
+			c.observer.OnEvict(evictedKey)
 		}
-		item.cond.L.Unlock()
+	}
+	atomic.AddUint64(&c.stats.misses, 1)
+	atomic.AddInt64(&c.stats.inFlight, 1)
+	if c.observer != nil {
+		c.observer.OnMiss(key)
+	}
+
+	go c.createEntry(cctx, key, item)
+
+	return c.wait(ctx, item, true)
+}
 
-		return item.val, true, item.deadline, item.err
+// createEntry runs the creation func for key and stores the result into item,
+// waking up everyone waiting on item.ready. It runs in its own goroutine so
+// that it can outlive the caller that first triggered it.
+func (c *Cache[K, V]) createEntry(ctx context.Context, key K, item *entry[K, V]) {
+	start := time.Now()
+	var val V
+	var deadline time.Time
+	var err error
+	if c.createCtx != nil {
+		val, deadline, err = c.createCtx(ctx, key)
+	} else {
+		val, deadline, err = c.create(key)
 	}
+	dur := time.Since(start)
 
-	val, deadline, err := c.create(key)
 	if err != nil {
 		cerr := &CreationError[K]{Key: key, Err: err}
 		c.mu.Lock()
-		item.cond.L.Lock()
+		item.mu.Lock()
 		item.err = cerr
-		delete(c.entries, key)
+		// item may no longer be the live entry for key: it may have already
+		// been evicted, deleted, or purged, possibly with a new entry for
+		// the same key already taking its place. Only remove it from the
+		// cache here if it is still that live entry, so this identity check
+		// can't end up deleting an unrelated, already-succeeded replacement.
+		if c.entries[key] == item {
+			delete(c.entries, key)
+			c.policy.Remove(key)
+		}
 		item.deleted = true
-		item.cond.Broadcast()
-		item.cond.L.Unlock()
+		close(item.ready)
+		item.mu.Unlock()
 		c.mu.Unlock()
 
-		return val, false, time.Time{}, cerr
+		c.stats.recordCreation(dur, cerr)
+		if c.observer != nil {
+			c.observer.OnCreate(key, dur, cerr)
+		}
+
+		return
 	}
 
-	c.mu.Lock()
-	item.cond.L.Lock()
+	item.mu.Lock()
 	item.val = val
 	item.deadline = deadline
 	if c.maxAge != 0 {
@@ -208,11 +368,67 @@ func (c *Cache[K, V]) Get(key K) (V, bool, time.Time, error) {
 		}
 	}
 	item.created = true
-	item.next, c.head.next.prev = c.head.next, item
-	c.head.next, item.prev = item, &c.head
-	item.cond.Broadcast()
-	item.cond.L.Unlock()
-	c.mu.Unlock()
+	close(item.ready)
+	item.mu.Unlock()
 
-	return item.val, false, item.deadline, nil
+	c.stats.recordCreation(dur, nil)
+	if c.observer != nil {
+		c.observer.OnCreate(key, dur, nil)
+	}
+}
+
+// wait blocks until item is created or deleted, or ctx is done, whichever
+// comes first. isCreator indicates that this call is the one that triggered
+// the creation, in which case a successful result is reported as not cached,
+// matching the behavior of Get.
+func (c *Cache[K, V]) wait(ctx context.Context, item *entry[K, V], isCreator bool) (V, bool, time.Time, error) {
+	select {
+	case <-item.ready:
+		item.mu.Lock()
+		val, deadline, err := item.val, item.deadline, item.err
+		item.mu.Unlock()
+		if err != nil {
+			var zero V
+			return zero, false, time.Time{}, err
+		}
+		return val, !isCreator, deadline, nil
+
+	case <-ctx.Done():
+		item.mu.Lock()
+		item.waiters--
+		giveUp := item.waiters <= 0
+		item.mu.Unlock()
+		if giveUp {
+			c.giveUpCreation(item)
+		}
+		var zero V
+		return zero, false, time.Time{}, ctx.Err()
+	}
+}
+
+// giveUpCreation cancels item's creation and evicts it from the cache, but
+// only if item is still the live, not-yet-created entry for its key and
+// still has no waiters left. The re-check under both locks, in the same
+// c.mu-then-item.mu order GetContext uses, is needed because a new caller
+// may have joined item (incrementing waiters) or its creation may have
+// already completed between the caller giving up and this call running.
+// Evicting item here, rather than just cancelling its context, is what lets
+// a subsequent Get/GetContext for the same key start a fresh creation
+// instead of joining one that is already doomed to fail with ctx.Err() for
+// a context that isn't even its own.
+func (c *Cache[K, V]) giveUpCreation(item *entry[K, V]) {
+	c.mu.Lock()
+	item.mu.Lock()
+	if item.waiters <= 0 && !item.created && !item.deleted {
+		item.deleted = true
+		if c.entries[item.key] == item {
+			delete(c.entries, item.key)
+			c.policy.Remove(item.key)
+		}
+		if item.cancel != nil {
+			item.cancel()
+		}
+	}
+	item.mu.Unlock()
+	c.mu.Unlock()
 }
@@ -5,8 +5,10 @@
 package cache_test
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -57,3 +59,102 @@ func TestGet_1(t *testing.T) {
 
 	c.CheckAndExpire()
 }
+
+func TestGetContext_Cancel(t *testing.T) {
+	started := make(chan struct{})
+	cfunc := func(ctx context.Context, key string) (string, time.Time, error) {
+		close(started)
+		<-ctx.Done()
+		return "", time.Time{}, ctx.Err()
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFuncCtx: cfunc,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, _, err := c.GetContext(ctx, "KEY-0")
+		if err != ctx.Err() {
+			t.Errorf("GetContext: got %v, want %v", err, ctx.Err())
+		}
+	}()
+
+	<-started
+	cancel()
+	wg.Wait()
+}
+
+// TestGetContext_HandoffAfterCancel checks that a joiner is not handed the
+// ctx.Err() of a creation it never cancelled. Caller A is the sole waiter for
+// a key and cancels its own context; once that has driven the shared
+// creation context to cancellation, caller B joins the same key with a fresh,
+// never-cancelled context. B must not receive the now-doomed creation's
+// error; it must instead trigger, and successfully wait for, a new creation.
+func TestGetContext_HandoffAfterCancel(t *testing.T) {
+	var calls int32
+	started := make(chan struct{}, 2)
+	releaseA := make(chan struct{})
+	cfunc := func(ctx context.Context, key string) (string, time.Time, error) {
+		n := atomic.AddInt32(&calls, 1)
+		started <- struct{}{}
+		if n == 1 {
+			<-ctx.Done()
+			<-releaseA
+			return "", time.Time{}, ctx.Err()
+		}
+		return fmt.Sprintf("VALUE(%s)#%d", key, n), time.Time{}, nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFuncCtx: cfunc,
+	})
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() {
+		defer close(doneA)
+		_, _, _, err := c.GetContext(ctxA, "KEY-0")
+		if err != ctxA.Err() {
+			t.Errorf("GetContext A: got %v, want %v", err, ctxA.Err())
+		}
+	}()
+
+	<-started
+	cancelA()
+	<-doneA // A's wait() has returned, so the shared creation context is cancelled
+
+	type resultB struct {
+		val    string
+		cached bool
+		err    error
+	}
+	resultCh := make(chan resultB, 1)
+	go func() {
+		val, cached, _, err := c.GetContext(context.Background(), "KEY-0")
+		resultCh <- resultB{val, cached, err}
+	}()
+
+	select {
+	case <-started: // B triggered a fresh creation instead of joining A's doomed one
+	case <-time.After(2 * time.Second):
+		t.Fatal("B never triggered a fresh creation; it is stuck waiting on A's cancelled one")
+	}
+	close(releaseA)
+
+	select {
+	case r := <-resultCh:
+		if r.err != nil {
+			t.Fatalf("GetContext B: %v", r.err)
+		}
+		if r.cached {
+			t.Error("GetContext B reported cached = true, want false")
+		}
+		if want := "VALUE(KEY-0)#2"; r.val != want {
+			t.Errorf("GetContext B = %q, want %q", r.val, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("GetContext B never returned")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache
+
+import "time"
+
+// startJanitor starts the background goroutine that calls CheckAndExpire
+// every interval, until Close is called.
+func (c *Cache[K, V]) startJanitor(interval time.Duration) {
+	c.janitorStop = make(chan struct{})
+	c.janitorDone = make(chan struct{})
+
+	go func() {
+		defer close(c.janitorDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.CheckAndExpire()
+			case <-c.janitorStop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background cleanup goroutine started via
+// Config.CleanupInterval, if any, and then purges all entries from the
+// cache, calling RemoveFunc for each one that had already been created. A
+// Cache remains usable after Close; Get will simply start repopulating it,
+// though no more background cleanup will run.
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		if c.janitorStop != nil {
+			close(c.janitorStop)
+			<-c.janitorDone
+		}
+	})
+	c.Purge()
+}
+
+// Delete removes key from the cache, if present, and reports whether it was
+// found. If key is still being created, any callers already waiting on it
+// are left undisturbed, but the entry is dropped from the cache so that a
+// subsequent Get starts a fresh creation.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	item, found := c.entries[key]
+	if !found {
+		c.mu.Unlock()
+
+		return false
+	}
+	delete(c.entries, key)
+	c.policy.Remove(key)
+	item.mu.Lock()
+	item.deleted = true
+	wasCreated, val := item.created, item.val
+	item.mu.Unlock()
+	c.mu.Unlock()
+
+	if wasCreated && c.remove != nil {
+		c.remove(key, val)
+	}
+
+	return true
+}
+
+// Purge removes all entries from the cache, calling RemoveFunc for each one
+// that had already been created. In-flight creations are left undisturbed,
+// as with Delete.
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	entries := c.entries
+	c.entries = make(map[K]*entry[K, V])
+	c.policy = newPolicy[K](c.policyKind, c.maxItems)
+	c.mu.Unlock()
+
+	for key, item := range entries {
+		item.mu.Lock()
+		item.deleted = true
+		wasCreated, val := item.created, item.val
+		item.mu.Unlock()
+		if wasCreated && c.remove != nil {
+			c.remove(key, val)
+		}
+	}
+}
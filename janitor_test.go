@@ -0,0 +1,125 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tunabay/go-cache"
+)
+
+func TestCache_DeletePurgeClose(t *testing.T) {
+	var removed []string
+	cfunc := func(key string) (string, time.Time, error) {
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: cfunc,
+		RemoveFunc: func(key string, _ string) { removed = append(removed, key) },
+	})
+
+	for _, key := range []string{"KEY-0", "KEY-1", "KEY-2"} {
+		if _, _, _, err := c.Get(key); err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+	}
+
+	if ok := c.Delete("KEY-0"); !ok {
+		t.Error("Delete(KEY-0) = false, want true")
+	}
+	if ok := c.Delete("KEY-0"); ok {
+		t.Error("Delete(KEY-0) again = true, want false")
+	}
+
+	c.Purge()
+	if len(removed) != 3 {
+		t.Errorf("removed = %v, want 3 entries", removed)
+	}
+
+	if _, _, _, err := c.Get("KEY-0"); err != nil {
+		t.Fatalf("Get(KEY-0) after purge: %v", err)
+	}
+
+	c.Close()
+}
+
+// TestCache_DeleteDuringCreation checks that an orphaned creation, started
+// before a Delete for the same key and still in flight when a new creation
+// for that key has already succeeded, cannot tear down the new, unrelated
+// entry when it finally finishes (successfully or not) after the fact.
+func TestCache_DeleteDuringCreation(t *testing.T) {
+	var calls int32
+	proceed1 := make(chan struct{})
+	started1 := make(chan struct{})
+	cfunc := func(key string) (string, time.Time, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started1)
+			<-proceed1
+			return "", time.Time{}, fmt.Errorf("test error")
+		}
+		return fmt.Sprintf("VALUE(%s)#2", key), time.Time{}, nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{CreateFunc: cfunc})
+
+	done1 := make(chan struct{})
+	go func() {
+		defer close(done1)
+		c.Get("KEY-0") // the orphaned creation; blocks until proceed1 is closed
+	}()
+	<-started1
+
+	if ok := c.Delete("KEY-0"); !ok {
+		t.Fatal("Delete(KEY-0) = false, want true")
+	}
+
+	val, cached, _, err := c.Get("KEY-0") // starts a brand new creation for KEY-0
+	if err != nil {
+		t.Fatalf("Get(KEY-0): %v", err)
+	}
+	if cached {
+		t.Error("Get(KEY-0) reported cached = true, want false")
+	}
+	if want := "VALUE(KEY-0)#2"; val != want {
+		t.Fatalf("Get(KEY-0) = %q, want %q", val, want)
+	}
+
+	close(proceed1) // let the orphaned creation finish (with an error) after the fact
+	<-done1
+
+	val, cached, _, err = c.Get("KEY-0")
+	if err != nil {
+		t.Fatalf("Get(KEY-0) after orphan finished: %v", err)
+	}
+	if !cached {
+		t.Error("Get(KEY-0) after orphan finished reported cached = false, want true: the live entry was torn down")
+	}
+	if want := "VALUE(KEY-0)#2"; val != want {
+		t.Errorf("Get(KEY-0) after orphan finished = %q, want %q", val, want)
+	}
+}
+
+func TestCache_CleanupInterval(t *testing.T) {
+	cfunc := func(key string) (string, time.Time, error) {
+		return fmt.Sprintf("VALUE(%s)", key), time.Now().Add(10 * time.Millisecond), nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc:      cfunc,
+		CleanupInterval: 10 * time.Millisecond,
+	})
+	defer c.Close()
+
+	if _, _, _, err := c.Get("KEY-0"); err != nil {
+		t.Fatalf("Get(KEY-0): %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if stats := c.Stats(); stats.Size != 0 {
+		t.Errorf("Size = %d, want 0 after background expiration", stats.Size)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache
+
+import "sort"
+
+// lfuNode tracks a single key's position within its frequency bucket.
+type lfuNode[K comparable] struct {
+	key        K
+	freq       uint64
+	bucket     *lfuBucket[K]
+	prev, next *lfuNode[K] // intrusive list within bucket
+}
+
+// lfuBucket groups all keys that currently share the same access frequency.
+type lfuBucket[K comparable] struct {
+	freq       uint64
+	prev, next *lfuBucket[K]
+	head, tail lfuNode[K]
+}
+
+func newLFUBucket[K comparable](freq uint64) *lfuBucket[K] {
+	b := &lfuBucket[K]{freq: freq}
+	b.head.next, b.tail.prev = &b.tail, &b.head
+
+	return b
+}
+
+func (b *lfuBucket[K]) empty() bool { return b.head.next == &b.tail }
+
+func (b *lfuBucket[K]) pushFront(n *lfuNode[K]) {
+	n.bucket = b
+	n.next, b.head.next.prev = b.head.next, n
+	b.head.next, n.prev = n, &b.head
+}
+
+func (b *lfuBucket[K]) remove(n *lfuNode[K]) {
+	n.prev.next, n.next.prev = n.next, n.prev
+}
+
+// lfuPolicy is an O(1) least-frequently-used eviction policy: every key is
+// linked into a bucket for its current access frequency, and buckets are
+// kept in ascending frequency order so the eviction victim is always found
+// in the lowest non-empty bucket without a scan.
+type lfuPolicy[K comparable] struct {
+	maxItems int
+	nodes    map[K]*lfuNode[K]
+	buckets  map[uint64]*lfuBucket[K]
+	min      *lfuBucket[K] // lowest non-empty bucket, nil if empty
+}
+
+func newLFUPolicy[K comparable](maxItems int) *lfuPolicy[K] {
+	return &lfuPolicy[K]{
+		maxItems: maxItems,
+		nodes:    make(map[K]*lfuNode[K]),
+		buckets:  make(map[uint64]*lfuBucket[K]),
+	}
+}
+
+// bucketFor returns the bucket for freq, creating and linking it in
+// ascending order right after after (after may be nil to mean "before the
+// lowest existing bucket") if it does not already exist.
+func (p *lfuPolicy[K]) bucketFor(freq uint64, after *lfuBucket[K]) *lfuBucket[K] {
+	if b, ok := p.buckets[freq]; ok {
+		return b
+	}
+	b := newLFUBucket[K](freq)
+	p.buckets[freq] = b
+	if after == nil {
+		if p.min != nil {
+			b.next, p.min.prev = p.min, b
+		}
+		p.min = b
+
+		return b
+	}
+	b.next, b.prev = after.next, after
+	if after.next != nil {
+		after.next.prev = b
+	}
+	after.next = b
+
+	return b
+}
+
+func (p *lfuPolicy[K]) dropBucketIfEmpty(b *lfuBucket[K]) {
+	if !b.empty() {
+		return
+	}
+	if b.prev != nil {
+		b.prev.next = b.next
+	}
+	if b.next != nil {
+		b.next.prev = b.prev
+	}
+	if p.min == b {
+		p.min = b.next
+	}
+	delete(p.buckets, b.freq)
+}
+
+func (p *lfuPolicy[K]) Touch(key K) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	old := n.bucket
+	old.remove(n)
+	nb := p.bucketFor(n.freq+1, old)
+	n.freq++
+	nb.pushFront(n)
+	p.dropBucketIfEmpty(old)
+}
+
+func (p *lfuPolicy[K]) Admit(key K) (K, bool) {
+	n := &lfuNode[K]{key: key, freq: 1}
+	p.nodes[key] = n
+	b := p.bucketFor(1, nil)
+	b.pushFront(n)
+
+	if p.maxItems == 0 || len(p.nodes) <= p.maxItems {
+		var zero K
+		return zero, false
+	}
+
+	victim := p.min.tail.prev
+	p.min.remove(victim)
+	p.dropBucketIfEmpty(p.min)
+	delete(p.nodes, victim.key)
+
+	return victim.key, true
+}
+
+func (p *lfuPolicy[K]) Remove(key K) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	b := n.bucket
+	b.remove(n)
+	p.dropBucketIfEmpty(b)
+	delete(p.nodes, key)
+}
+
+// order returns keys from the highest-frequency bucket down to the lowest,
+// since the lowest is where the next eviction comes from.
+func (p *lfuPolicy[K]) order() []K {
+	freqs := make([]uint64, 0, len(p.buckets))
+	for f := range p.buckets {
+		freqs = append(freqs, f)
+	}
+	sort.Slice(freqs, func(i, j int) bool { return freqs[i] > freqs[j] })
+
+	keys := make([]K, 0, len(p.nodes))
+	for _, f := range freqs {
+		b := p.buckets[f]
+		for n := b.head.next; n != &b.tail; n = n.next {
+			keys = append(keys, n.key)
+		}
+	}
+
+	return keys
+}
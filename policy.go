@@ -0,0 +1,136 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache
+
+// Policy selects the eviction policy used by a Cache to decide which key to
+// evict when the number of entries would otherwise exceed Config.MaxItems.
+type Policy int
+
+const (
+	// PolicyLRU evicts the least recently used key. This is the default and
+	// matches the behavior of earlier versions of this package.
+	PolicyLRU Policy = iota
+
+	// PolicyLFU evicts the least frequently used key, tracked with O(1)
+	// frequency buckets. It tends to outperform LRU for workloads with a
+	// stable set of hot keys, but adapts poorly to shifting access patterns
+	// since old frequency counts never decay.
+	PolicyLFU
+
+	// PolicyTinyLFU uses a W-TinyLFU admission policy: a small window LRU
+	// in front of a segmented main cache, with admission to the main cache
+	// decided by an approximate, aging frequency sketch. It generally gives
+	// the best hit ratio across both skewed and scanning workloads.
+	PolicyTinyLFU
+)
+
+// evictionPolicy tracks insertion and access order for cache keys and
+// decides which key, if any, to evict when a new key is admitted. It does
+// not store values; Cache.entries remains the source of truth for those.
+// All methods are called with Cache.mu held, so implementations need not be
+// safe for concurrent use on their own.
+type evictionPolicy[K comparable] interface {
+	// Touch records a cache hit for key, which must already be tracked via a
+	// prior call to Admit.
+	Touch(key K)
+
+	// Admit records the insertion of a newly created key into the cache. If
+	// this causes the cache to exceed its capacity, or the policy decides
+	// key itself should not be kept, Admit returns the key to evict and ok
+	// true. The returned key may be key itself, meaning the entry that was
+	// just created should not be retained.
+	Admit(key K) (victim K, ok bool)
+
+	// Remove stops tracking key, e.g. because it expired or was removed
+	// through some other path than eviction.
+	Remove(key K)
+
+	// order returns every currently tracked key, ordered from most to
+	// least valuable, i.e. the order in which Cache.Save writes entries.
+	order() []K
+}
+
+// newPolicy constructs the evictionPolicy selected by kind for a cache with
+// the given capacity. maxItems of 0 means unlimited.
+func newPolicy[K comparable](kind Policy, maxItems int) evictionPolicy[K] {
+	switch kind {
+	case PolicyLFU:
+		return newLFUPolicy[K](maxItems)
+	case PolicyTinyLFU:
+		return newTinyLFUPolicy[K](maxItems)
+	default:
+		return newLRUPolicy[K](maxItems)
+	}
+}
+
+// lruNode is a node in lruPolicy's intrusive doubly linked list.
+type lruNode[K comparable] struct {
+	key        K
+	prev, next *lruNode[K]
+}
+
+// lruPolicy is the classic least-recently-used eviction policy. It is the
+// same algorithm Cache used before eviction policies became pluggable.
+type lruPolicy[K comparable] struct {
+	maxItems   int
+	nodes      map[K]*lruNode[K]
+	head, tail lruNode[K]
+}
+
+func newLRUPolicy[K comparable](maxItems int) *lruPolicy[K] {
+	p := &lruPolicy[K]{
+		maxItems: maxItems,
+		nodes:    make(map[K]*lruNode[K]),
+	}
+	p.head.next, p.tail.prev = &p.tail, &p.head
+
+	return p
+}
+
+func (p *lruPolicy[K]) Touch(key K) {
+	n, ok := p.nodes[key]
+	if !ok || p.head.next == n {
+		return
+	}
+	n.prev.next, n.next.prev = n.next, n.prev
+	n.next, p.head.next.prev = p.head.next, n
+	p.head.next, n.prev = n, &p.head
+}
+
+func (p *lruPolicy[K]) Admit(key K) (K, bool) {
+	n := &lruNode[K]{key: key}
+	p.nodes[key] = n
+	n.next, p.head.next.prev = p.head.next, n
+	p.head.next, n.prev = n, &p.head
+
+	if p.maxItems == 0 || len(p.nodes) <= p.maxItems {
+		var zero K
+		return zero, false
+	}
+
+	victim := p.tail.prev
+	victim.prev.next, p.tail.prev = &p.tail, victim.prev
+	delete(p.nodes, victim.key)
+
+	return victim.key, true
+}
+
+func (p *lruPolicy[K]) Remove(key K) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	n.prev.next, n.next.prev = n.next, n.prev
+	delete(p.nodes, key)
+}
+
+func (p *lruPolicy[K]) order() []K {
+	keys := make([]K, 0, len(p.nodes))
+	for n := p.head.next; n != &p.tail; n = n.next {
+		keys = append(keys, n.key)
+	}
+
+	return keys
+}
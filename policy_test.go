@@ -0,0 +1,174 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tunabay/go-cache"
+)
+
+// TestPolicy_MaxItems inserts far more unique keys than MaxItems under each
+// eviction policy and checks that the cache size never exceeds MaxItems. This
+// specifically guards against a TinyLFU admission bug where, once the
+// protected segment was still empty, a missing fallback victim caused the
+// cache to silently grow one entry past MaxItems and stay there.
+func TestPolicy_MaxItems(t *testing.T) {
+	for _, policy := range []cache.Policy{cache.PolicyLRU, cache.PolicyLFU, cache.PolicyTinyLFU} {
+		policy := policy
+		t.Run(fmt.Sprint(policy), func(t *testing.T) {
+			for _, maxItems := range []int{1, 2, 3, 5, 10, 20, 50} {
+				maxItems := maxItems
+				t.Run(fmt.Sprint(maxItems), func(t *testing.T) {
+					cfunc := func(key string) (string, time.Time, error) {
+						return key, time.Time{}, nil
+					}
+					c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+						CreateFunc: cfunc,
+						MaxItems:   maxItems,
+						Policy:     policy,
+					})
+
+					for i := 0; i < maxItems*10; i++ {
+						key := fmt.Sprintf("KEY-%d", i)
+						if _, _, _, err := c.Get(key); err != nil {
+							t.Fatalf("Get(%q): %v", key, err)
+						}
+						if size := c.Stats().Size; size > maxItems {
+							t.Fatalf("after Get(%q): Size = %d, want <= %d", key, size, maxItems)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestPolicy_LRUEvictsLeastRecentlyUsed checks the defining behavior of
+// PolicyLRU: touching a key moves it to the front, so the key that is never
+// touched again is the one evicted.
+func TestPolicy_LRUEvictsLeastRecentlyUsed(t *testing.T) {
+	var calls int32
+	cfunc := func(key string) (string, time.Time, error) {
+		calls++
+		return fmt.Sprintf("VALUE(%s)#%d", key, calls), time.Time{}, nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: cfunc,
+		MaxItems:   2,
+		Policy:     cache.PolicyLRU,
+	})
+
+	mustGet := func(key string) string {
+		t.Helper()
+		val, _, _, err := c.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		return val
+	}
+
+	val0 := mustGet("KEY-0")
+	val1 := mustGet("KEY-1")
+	mustGet("KEY-0") // touch KEY-0 so KEY-1 becomes the least recently used
+	mustGet("KEY-2") // over capacity: should evict KEY-1, not KEY-0
+
+	if got := mustGet("KEY-0"); got != val0 {
+		t.Errorf("Get(KEY-0) = %q, want cached %q (should not have been evicted)", got, val0)
+	}
+	if got := mustGet("KEY-1"); got == val1 {
+		t.Errorf("Get(KEY-1) = %q, want a freshly created value (should have been evicted)", got)
+	}
+}
+
+// TestPolicy_LFUEvictsLeastFrequentlyUsed checks the defining behavior of
+// PolicyLFU: a key touched many times outranks one touched only once,
+// regardless of which was created or last touched more recently.
+func TestPolicy_LFUEvictsLeastFrequentlyUsed(t *testing.T) {
+	var calls int32
+	cfunc := func(key string) (string, time.Time, error) {
+		calls++
+		return fmt.Sprintf("VALUE(%s)#%d", key, calls), time.Time{}, nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: cfunc,
+		MaxItems:   2,
+		Policy:     cache.PolicyLFU,
+	})
+
+	mustGet := func(key string) string {
+		t.Helper()
+		val, _, _, err := c.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		return val
+	}
+
+	hot := mustGet("HOT")
+	for i := 0; i < 5; i++ {
+		mustGet("HOT") // repeatedly touched, so its frequency far outpaces any once-touched key
+	}
+	cold := mustGet("COLD-1")
+	mustGet("COLD-2") // over capacity: should evict the least frequently used key, COLD-1, not HOT
+
+	if got := mustGet("HOT"); got != hot {
+		t.Errorf("Get(HOT) = %q, want cached %q (should not have been evicted)", got, hot)
+	}
+	if got := mustGet("COLD-1"); got == cold {
+		t.Errorf("Get(COLD-1) = %q, want a freshly created value (should have been evicted)", got)
+	}
+}
+
+// TestPolicy_TinyLFURetainsHotKeyOverScan checks the defining behavior of
+// PolicyTinyLFU: a key that was hit many times before being pushed out of the
+// window segment outranks a long stream of one-off scan keys in the
+// admission contest for the main segment, thanks to its higher estimated
+// frequency in the count-min sketch. This specifically guards against a
+// reversed admission comparison, under which the scan would win every
+// contest instead and HOT would never survive.
+func TestPolicy_TinyLFURetainsHotKeyOverScan(t *testing.T) {
+	var calls int32
+	cfunc := func(key string) (string, time.Time, error) {
+		calls++
+		return fmt.Sprintf("VALUE(%s)#%d", key, calls), time.Time{}, nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: cfunc,
+		MaxItems:   5,
+		Policy:     cache.PolicyTinyLFU,
+	})
+
+	mustGet := func(key string) string {
+		t.Helper()
+		val, _, _, err := c.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		return val
+	}
+
+	hot := mustGet("HOT")
+	// Build up HOT's estimated frequency well above anything a one-off scan
+	// key could ever reach, before the scan starts pushing it toward the
+	// back of the window and into admission contests.
+	for i := 0; i < 50; i++ {
+		mustGet("HOT")
+	}
+
+	scan0 := mustGet("SCAN-0")
+	for i := 1; i < 200; i++ {
+		mustGet(fmt.Sprintf("SCAN-%d", i))
+	}
+
+	if got := mustGet("HOT"); got != hot {
+		t.Errorf("Get(HOT) = %q, want cached %q (should have survived the scan)", got, hot)
+	}
+	if got := mustGet("SCAN-0"); got == scan0 {
+		t.Errorf("Get(SCAN-0) = %q, want a freshly created value (should have been evicted by the scan itself)", got)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+// Package promcache provides a cache.Observer implementation that reports
+// Cache activity as Prometheus metrics.
+package promcache
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	cache "github.com/tunabay/go-cache"
+)
+
+// Opts configures the metrics registered by New. Name is used as the metric
+// name prefix, e.g. Name "mycache" registers "mycache_hits_total".
+type Opts struct {
+	Namespace string
+	Name      string
+}
+
+// Observer is a cache.Observer that records cache activity as Prometheus
+// metrics. It does not label metrics by key, to avoid unbounded
+// cardinality; use one Observer (and one set of registered metrics) per
+// Cache instance you want to track separately.
+type Observer[K comparable] struct {
+	hits, misses, evictions, expirations, creationErrors prometheus.Counter
+	creationDuration                                     prometheus.Histogram
+}
+
+var _ cache.Observer[string] = (*Observer[string])(nil)
+
+// New creates an Observer and registers its metrics with reg.
+func New[K comparable](reg prometheus.Registerer, opts Opts) (*Observer[K], error) {
+	o := &Observer[K]{
+		hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      opts.Name + "_hits_total",
+			Help:      "Total number of cache hits.",
+		}),
+		misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      opts.Name + "_misses_total",
+			Help:      "Total number of cache misses that triggered creation.",
+		}),
+		evictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      opts.Name + "_evictions_total",
+			Help:      "Total number of entries evicted to make room for a new key.",
+		}),
+		expirations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      opts.Name + "_expirations_total",
+			Help:      "Total number of entries removed for exceeding their deadline.",
+		}),
+		creationErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      opts.Name + "_creation_errors_total",
+			Help:      "Total number of CreateFunc/CreateFuncCtx calls that returned an error.",
+		}),
+		creationDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      opts.Name + "_creation_duration_seconds",
+			Help:      "Duration of CreateFunc/CreateFuncCtx calls.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+
+	collectors := []prometheus.Collector{
+		o.hits, o.misses, o.evictions, o.expirations, o.creationErrors, o.creationDuration,
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// OnHit implements cache.Observer.
+func (o *Observer[K]) OnHit(K) { o.hits.Inc() }
+
+// OnMiss implements cache.Observer.
+func (o *Observer[K]) OnMiss(K) { o.misses.Inc() }
+
+// OnEvict implements cache.Observer.
+func (o *Observer[K]) OnEvict(K) { o.evictions.Inc() }
+
+// OnExpire implements cache.Observer.
+func (o *Observer[K]) OnExpire(K) { o.expirations.Inc() }
+
+// OnCreate implements cache.Observer.
+func (o *Observer[K]) OnCreate(_ K, dur time.Duration, err error) {
+	o.creationDuration.Observe(dur.Seconds())
+	if err != nil {
+		o.creationErrors.Inc()
+	}
+}
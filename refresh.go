@@ -0,0 +1,79 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Refresh forces a background reload of key via CreateFunc/CreateFuncCtx,
+// replacing its cached value in place once the reload completes. It has no
+// effect if key is not currently cached, or if a refresh for key, whether
+// triggered by RefreshAhead or a prior call to Refresh, is already running.
+func (c *Cache[K, V]) Refresh(key K) {
+	c.mu.Lock()
+	item, found := c.entries[key]
+	if !found {
+		c.mu.Unlock()
+
+		return
+	}
+	item.mu.Lock()
+	if !item.created || item.refreshing {
+		item.mu.Unlock()
+		c.mu.Unlock()
+
+		return
+	}
+	item.refreshing = true
+	item.mu.Unlock()
+	c.mu.Unlock()
+
+	atomic.AddInt64(&c.stats.inFlight, 1)
+	go c.refresh(key, item)
+}
+
+// refresh re-runs the creation func for key and replaces item's value and
+// deadline in place once it completes, clearing item.refreshing so that a
+// later hit or Refresh call can trigger another reload. Unlike createEntry,
+// it does not touch item.ready: item is already visible to callers, which
+// keep getting the stale value until refresh replaces it, instead of
+// blocking on a new creation.
+func (c *Cache[K, V]) refresh(key K, item *entry[K, V]) {
+	start := time.Now()
+	var val V
+	var deadline time.Time
+	var err error
+	if c.createCtx != nil {
+		val, deadline, err = c.createCtx(context.Background(), key)
+	} else {
+		val, deadline, err = c.create(key)
+	}
+	dur := time.Since(start)
+
+	item.mu.Lock()
+	if err == nil && !item.deleted {
+		item.val = val
+		item.deadline = deadline
+		if c.maxAge != 0 {
+			expire := time.Now().Add(c.maxAge)
+			if item.deadline.IsZero() || expire.Before(item.deadline) {
+				item.deadline = expire
+			}
+		}
+	}
+	item.refreshing = false
+	item.mu.Unlock()
+
+	if err != nil {
+		err = &CreationError[K]{Key: key, Err: err}
+	}
+	c.stats.recordCreation(dur, err)
+	if c.observer != nil {
+		c.observer.OnCreate(key, dur, err)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tunabay/go-cache"
+)
+
+func TestCache_RefreshAhead(t *testing.T) {
+	var gen int32
+	cfunc := func(key string) (string, time.Time, error) {
+		n := atomic.AddInt32(&gen, 1)
+		return fmt.Sprintf("VALUE(%s)#%d", key, n), time.Now().Add(60 * time.Millisecond), nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc:   cfunc,
+		RefreshAhead: 40 * time.Millisecond,
+	})
+
+	val1, cached, _, err := c.Get("KEY-0")
+	if err != nil {
+		t.Fatalf("Get(KEY-0): %v", err)
+	}
+	if cached {
+		t.Error("first Get reported cached = true, want false")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+
+	// Remaining TTL is now below RefreshAhead, so this hit should trigger a
+	// background refresh while still returning the stale value.
+	val2, _, _, err := c.Get("KEY-0")
+	if err != nil {
+		t.Fatalf("Get(KEY-0): %v", err)
+	}
+	if val2 != val1 {
+		t.Errorf("Get(KEY-0) = %q, want stale value %q", val2, val1)
+	}
+
+	// The previous Get only triggered the background refresh; it runs
+	// concurrently, so poll until it has replaced the stale value.
+	deadline := time.Now().Add(1 * time.Second)
+	var val3 string
+	for {
+		val3, _, _, err = c.Get("KEY-0")
+		if err != nil {
+			t.Fatalf("Get(KEY-0): %v", err)
+		}
+		if val3 != val1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Get(KEY-0) = %q, want a refreshed value", val3)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
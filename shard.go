@@ -0,0 +1,240 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// defaultShards is the number of shards a ShardedCache uses when
+// ShardedConfig.Shards is not set.
+const defaultShards = 16
+
+// HashFunc computes a hash for a key, used by ShardedCache to pick the shard
+// that owns it. It does not need to be cryptographically strong, only fast
+// and well distributed.
+type HashFunc[K comparable] func(K) uint64
+
+// ShardedCache partitions keys across a fixed number of independently
+// locked Cache instances. A plain Cache serializes every Get behind a single
+// mutex, which becomes a bottleneck under high concurrency; spreading keys
+// across shards reduces contention in proportion to the number of shards.
+type ShardedCache[K comparable, V any] struct {
+	shards []*Cache[K, V]
+	hash   HashFunc[K]
+}
+
+// ShardedConfig is the config parameter set which is passed to NewSharded.
+// It mirrors Config, plus sharding-specific fields.
+type ShardedConfig[K comparable, V any] struct {
+	// CreateFunc is the function to create a new cacheable object. It is
+	// called when Get is called for a key that does not exist in the cache.
+	CreateFunc CreateFunc[K, V]
+
+	// CreateFuncCtx is the context-aware variant of CreateFunc. See
+	// Config.CreateFuncCtx.
+	CreateFuncCtx CreateFuncCtx[K, V]
+
+	// RemoveFunc is the optional function that is called immediately after
+	// a cache entry is removed from the cache.
+	RemoveFunc RemoveFunc[K, V]
+
+	// MaxItems is the maximum number of items that the cache can hold in
+	// total, divided evenly across shards: each shard holds at most
+	// MaxItems/Shards items, rounded down, but at least one. The total limit
+	// is therefore below MaxItems when MaxItems is not a multiple of Shards,
+	// and equal to Shards when MaxItems is less than Shards. 0 indicates
+	// unlimited.
+	MaxItems int
+
+	// MaxAge is the maximum time since an item was created and cached.
+	// 0 indicates unlimited.
+	MaxAge time.Duration
+
+	// Policy selects the eviction policy used by each shard.
+	Policy Policy
+
+	// Observer, if set, is notified of cache hits, misses, evictions,
+	// expirations, and creations on every shard. See the Observer type.
+	Observer Observer[K]
+
+	// CleanupInterval, if non-zero, starts a background goroutine on every
+	// shard that calls CheckAndExpire on this interval. Call Close to stop
+	// them and release their resources.
+	CleanupInterval time.Duration
+
+	// RefreshAhead enables stale-while-revalidate behavior on every shard.
+	// See Config.RefreshAhead.
+	RefreshAhead time.Duration
+
+	// Shards is the number of independently locked Cache instances to
+	// partition keys across. 0 selects a default of 16.
+	Shards int
+
+	// HashFunc computes the shard for a key. If nil, a default based on
+	// hash/maphash is used, which handles strings, []byte, and the built-in
+	// integer types directly and falls back to hashing fmt.Sprintf("%v", key)
+	// for anything else.
+	HashFunc HashFunc[K]
+}
+
+// NewSharded creates a ShardedCache with the specified configuration.
+func NewSharded[K comparable, V any](conf *ShardedConfig[K, V]) *ShardedCache[K, V] {
+	n := conf.Shards
+	if n <= 0 {
+		n = defaultShards
+	}
+	hash := conf.HashFunc
+	if hash == nil {
+		hash = defaultHashFunc[K]()
+	}
+
+	perShard := 0
+	if conf.MaxItems != 0 {
+		perShard = conf.MaxItems / n
+		if perShard == 0 {
+			perShard = 1
+		}
+	}
+
+	sc := &ShardedCache[K, V]{
+		shards: make([]*Cache[K, V], n),
+		hash:   hash,
+	}
+	for i := range sc.shards {
+		sc.shards[i] = NewWithConfig[K, V](&Config[K, V]{
+			CreateFunc:      conf.CreateFunc,
+			CreateFuncCtx:   conf.CreateFuncCtx,
+			RemoveFunc:      conf.RemoveFunc,
+			MaxItems:        perShard,
+			MaxAge:          conf.MaxAge,
+			Policy:          conf.Policy,
+			Observer:        conf.Observer,
+			CleanupInterval: conf.CleanupInterval,
+			RefreshAhead:    conf.RefreshAhead,
+		})
+	}
+
+	return sc
+}
+
+// shardFor returns the Cache that owns key.
+func (sc *ShardedCache[K, V]) shardFor(key K) *Cache[K, V] {
+	return sc.shards[sc.hash(key)%uint64(len(sc.shards))]
+}
+
+// Get gets the value for the key from the owning shard. See Cache.Get.
+func (sc *ShardedCache[K, V]) Get(key K) (V, bool, time.Time, error) {
+	return sc.shardFor(key).Get(key)
+}
+
+// GetContext is like Get, but takes a context.Context. See Cache.GetContext.
+func (sc *ShardedCache[K, V]) GetContext(ctx context.Context, key K) (V, bool, time.Time, error) {
+	return sc.shardFor(key).GetContext(ctx, key)
+}
+
+// CheckAndExpire checks all the items in every shard and removes expired
+// items. Shards are checked concurrently. See Cache.CheckAndExpire.
+func (sc *ShardedCache[K, V]) CheckAndExpire() {
+	var wg sync.WaitGroup
+	wg.Add(len(sc.shards))
+	for _, s := range sc.shards {
+		go func(s *Cache[K, V]) {
+			defer wg.Done()
+			s.CheckAndExpire()
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Refresh forces a background reload of key on its owning shard. See
+// Cache.Refresh.
+func (sc *ShardedCache[K, V]) Refresh(key K) {
+	sc.shardFor(key).Refresh(key)
+}
+
+// Delete removes key from its owning shard. See Cache.Delete.
+func (sc *ShardedCache[K, V]) Delete(key K) bool {
+	return sc.shardFor(key).Delete(key)
+}
+
+// Purge removes all entries from every shard. See Cache.Purge.
+func (sc *ShardedCache[K, V]) Purge() {
+	var wg sync.WaitGroup
+	wg.Add(len(sc.shards))
+	for _, s := range sc.shards {
+		go func(s *Cache[K, V]) {
+			defer wg.Done()
+			s.Purge()
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Close stops every shard's background cleanup goroutine, if any, and
+// purges all entries. See Cache.Close.
+func (sc *ShardedCache[K, V]) Close() {
+	var wg sync.WaitGroup
+	wg.Add(len(sc.shards))
+	for _, s := range sc.shards {
+		go func(s *Cache[K, V]) {
+			defer wg.Done()
+			s.Close()
+		}(s)
+	}
+	wg.Wait()
+}
+
+// defaultHashFunc returns a HashFunc that handles strings, []byte, and the
+// built-in integer types directly via hash/maphash, falling back to hashing
+// fmt.Sprintf("%v", key) for anything else.
+func defaultHashFunc[K comparable]() HashFunc[K] {
+	seed := maphash.MakeSeed()
+
+	return func(key K) uint64 {
+		switch k := any(key).(type) {
+		case string:
+			return maphash.String(seed, k)
+		case []byte:
+			return maphash.Bytes(seed, k)
+		case int:
+			return hashUint64(seed, uint64(k))
+		case int8:
+			return hashUint64(seed, uint64(k))
+		case int16:
+			return hashUint64(seed, uint64(k))
+		case int32:
+			return hashUint64(seed, uint64(k))
+		case int64:
+			return hashUint64(seed, uint64(k))
+		case uint:
+			return hashUint64(seed, uint64(k))
+		case uint8:
+			return hashUint64(seed, uint64(k))
+		case uint16:
+			return hashUint64(seed, uint64(k))
+		case uint32:
+			return hashUint64(seed, uint64(k))
+		case uint64:
+			return hashUint64(seed, k)
+		case uintptr:
+			return hashUint64(seed, uint64(k))
+		default:
+			return maphash.String(seed, fmt.Sprintf("%v", k))
+		}
+	}
+}
+
+func hashUint64(seed maphash.Seed, v uint64) uint64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+
+	return maphash.Bytes(seed, buf[:])
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tunabay/go-cache"
+)
+
+func TestShardedCache_Get(t *testing.T) {
+	var calls sync.Map // key -> call count
+	cfunc := func(key string) (string, time.Time, error) {
+		n, _ := calls.LoadOrStore(key, new(int32))
+		*n.(*int32)++
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+	sc := cache.NewSharded[string, string](&cache.ShardedConfig[string, string]{
+		CreateFunc: cfunc,
+		MaxItems:   64,
+		Shards:     4,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			key := fmt.Sprintf("KEY-%d", n%4)
+			val, _, _, err := sc.Get(key)
+			if err != nil {
+				t.Errorf("Get(%q): %v", key, err)
+				return
+			}
+			want := fmt.Sprintf("VALUE(%s)", key)
+			if val != want {
+				t.Errorf("Get(%q) = %q, want %q", key, val, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sc.CheckAndExpire()
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Codec encodes and decodes cache entries for Cache.Save and Cache.Load. Set
+// Config.Codec to use one instead of the default encoding/gob-based format,
+// e.g. when K or V cannot be registered with encoding/gob.
+type Codec[K comparable, V any] interface {
+	// Encode writes one entry to w.
+	Encode(w io.Writer, key K, val V, deadline time.Time) error
+
+	// Decode reads one entry from r. It returns io.EOF, with zero values
+	// for key, val and deadline, once there is nothing left to read.
+	Decode(r io.Reader) (key K, val V, deadline time.Time, err error)
+}
+
+// gobEntry is the on-the-wire representation of one entry in the default,
+// encoding/gob-based Save/Load format.
+type gobEntry[K comparable, V any] struct {
+	Key      K
+	Val      V
+	Deadline time.Time
+}
+
+// Save writes every live entry in the cache to w, most valuable first
+// according to the cache's eviction policy, using encoding/gob, or
+// Config.Codec if set. It does not save entries that are still being
+// created.
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	c.mu.Lock()
+	order := c.policy.order()
+	type snapshotEntry struct {
+		key      K
+		val      V
+		deadline time.Time
+	}
+	toSave := make([]snapshotEntry, 0, len(order))
+	for _, key := range order {
+		item, ok := c.entries[key]
+		if !ok {
+			continue
+		}
+		item.mu.Lock()
+		if item.created && !item.deleted {
+			toSave = append(toSave, snapshotEntry{key, item.val, item.deadline})
+		}
+		item.mu.Unlock()
+	}
+	c.mu.Unlock()
+
+	if c.codec != nil {
+		for _, e := range toSave {
+			if err := c.codec.Encode(w, e.key, e.val, e.deadline); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	enc := gob.NewEncoder(w)
+	for _, e := range toSave {
+		if err := enc.Encode(gobEntry[K, V]{Key: e.key, Val: e.val, Deadline: e.deadline}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveFile is like Save, but writes to the file at path, creating or
+// truncating it as needed.
+func (c *Cache[K, V]) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := c.Save(w); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// Load reads entries previously written by Save from r and adds them to the
+// cache, respecting MaxItems and the configured eviction policy exactly as
+// Get does when admitting a newly created entry. Entries already expired by
+// the time they are read are skipped. Load does not clear the cache first;
+// call Purge first for a clean restore.
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	now := time.Now()
+
+	if c.codec != nil {
+		for {
+			key, val, deadline, err := c.codec.Decode(r)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !deadline.IsZero() && !deadline.After(now) {
+				continue
+			}
+			c.loadEntry(key, val, deadline)
+		}
+	}
+
+	dec := gob.NewDecoder(r)
+	for {
+		var ge gobEntry[K, V]
+		switch err := dec.Decode(&ge); {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+		if !ge.Deadline.IsZero() && !ge.Deadline.After(now) {
+			continue
+		}
+		c.loadEntry(ge.Key, ge.Val, ge.Deadline)
+	}
+}
+
+// LoadFile is like Load, but reads from the file at path.
+func (c *Cache[K, V]) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Load(bufio.NewReader(f))
+}
+
+// loadEntry admits one already-created key/value/deadline into the cache,
+// evicting a victim via the configured policy exactly as a fresh creation
+// would. It is a no-op if key is already present.
+func (c *Cache[K, V]) loadEntry(key K, val V, deadline time.Time) {
+	c.mu.Lock()
+	if _, exists := c.entries[key]; exists {
+		c.mu.Unlock()
+
+		return
+	}
+
+	item := &entry[K, V]{
+		key:      key,
+		ready:    make(chan struct{}),
+		created:  true,
+		val:      val,
+		deadline: deadline,
+	}
+	close(item.ready)
+	c.entries[key] = item
+
+	var victimKey K
+	var victimVal V
+	var evictCreated bool
+	if victim, evict := c.policy.Admit(key); evict {
+		if victimItem, ok := c.entries[victim]; ok {
+			delete(c.entries, victim)
+			victimItem.mu.Lock()
+			victimItem.deleted = true
+			evictCreated, victimVal = victimItem.created, victimItem.val
+			victimItem.mu.Unlock()
+			victimKey = victim
+		}
+	}
+	c.mu.Unlock()
+
+	if evictCreated && c.remove != nil {
+		c.remove(victimKey, victimVal)
+	}
+	if evictCreated {
+		atomic.AddUint64(&c.stats.evictions, 1)
+		if c.observer != nil {
+			c.observer.OnEvict(victimKey)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/tunabay/go-cache"
+)
+
+func TestCache_SaveLoad(t *testing.T) {
+	cfunc := func(key string) (string, time.Time, error) {
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+	c1 := cache.New[string, string](cfunc)
+	for _, key := range []string{"KEY-0", "KEY-1", "KEY-2"} {
+		if _, _, _, err := c1.Get(key); err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c1.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	c2 := cache.New[string, string](func(string) (string, time.Time, error) {
+		t.Fatal("CreateFunc should not be called for an entry restored by Load")
+		return "", time.Time{}, nil
+	})
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	val, _, _, err := c2.Get("KEY-1")
+	if err != nil {
+		t.Fatalf("Get(KEY-1): %v", err)
+	}
+	if want := "VALUE(KEY-1)"; val != want {
+		t.Errorf("Get(KEY-1) = %q, want %q", val, want)
+	}
+	if stats := c2.Stats(); stats.Size != 3 {
+		t.Errorf("Size = %d, want 3", stats.Size)
+	}
+}
+
+// TestCache_LoadSkipsExpiredEntries checks that Load skips entries whose
+// deadline has already passed by the time they are read, rather than
+// reviving them.
+func TestCache_LoadSkipsExpiredEntries(t *testing.T) {
+	cfunc := func(key string) (string, time.Time, error) {
+		if key == "KEY-EXP" {
+			return fmt.Sprintf("VALUE(%s)", key), time.Now().Add(time.Millisecond), nil
+		}
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+	c1 := cache.New[string, string](cfunc)
+	for _, key := range []string{"KEY-EXP", "KEY-LIVE"} {
+		if _, _, _, err := c1.Get(key); err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond) // let KEY-EXP's deadline pass before Save
+
+	var buf bytes.Buffer
+	if err := c1.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	c2 := cache.New[string, string](func(key string) (string, time.Time, error) {
+		return fmt.Sprintf("FRESH(%s)", key), time.Time{}, nil
+	})
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if stats := c2.Stats(); stats.Size != 1 {
+		t.Errorf("Size = %d, want 1 (KEY-EXP should have been skipped)", stats.Size)
+	}
+	if val, _, _, err := c2.Get("KEY-EXP"); err != nil || val != "FRESH(KEY-EXP)" {
+		t.Errorf("Get(KEY-EXP) = (%q, %v), want a freshly created value", val, err)
+	}
+	if val, _, _, err := c2.Get("KEY-LIVE"); err != nil || val != "VALUE(KEY-LIVE)" {
+		t.Errorf("Get(KEY-LIVE) = (%q, %v), want the restored value", val, err)
+	}
+}
+
+// TestCache_LoadRespectsMaxItems checks that Load admits entries through the
+// configured eviction policy, so a snapshot with more entries than MaxItems
+// ends up evicting down to size rather than overflowing it.
+func TestCache_LoadRespectsMaxItems(t *testing.T) {
+	cfunc := func(key string) (string, time.Time, error) {
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+	c1 := cache.New[string, string](cfunc)
+	var keys []string
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("KEY-%d", i)
+		keys = append(keys, key)
+		if _, _, _, err := c1.Get(key); err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c1.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	c2 := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: cfunc,
+		MaxItems:   3,
+		Policy:     cache.PolicyLRU,
+	})
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if stats := c2.Stats(); stats.Size != 3 {
+		t.Errorf("Size = %d, want 3 (MaxItems should have been enforced during Load)", stats.Size)
+	}
+}
+
+// fixedWidthCodec is a minimal cache.Codec for string keys and values, used
+// to check that Save/Load round-trip correctly through a custom Codec
+// instead of the default gob format.
+type fixedWidthCodec struct{}
+
+func (fixedWidthCodec) Encode(w io.Writer, key, val string, deadline time.Time) error {
+	var nanos int64
+	if !deadline.IsZero() {
+		nanos = deadline.UnixNano()
+	}
+	_, err := fmt.Fprintf(w, "%d:%s|%d:%s|%d\n", len(key), key, len(val), val, nanos)
+
+	return err
+}
+
+func (fixedWidthCodec) Decode(r io.Reader) (key, val string, deadline time.Time, err error) {
+	var keyLen, valLen int
+	var nanos int64
+	if _, err = fmt.Fscanf(r, "%d:", &keyLen); err != nil {
+		return "", "", time.Time{}, err
+	}
+	keyBuf := make([]byte, keyLen)
+	if _, err = io.ReadFull(r, keyBuf); err != nil {
+		return "", "", time.Time{}, err
+	}
+	if _, err = fmt.Fscanf(r, "|%d:", &valLen); err != nil {
+		return "", "", time.Time{}, err
+	}
+	valBuf := make([]byte, valLen)
+	if _, err = io.ReadFull(r, valBuf); err != nil {
+		return "", "", time.Time{}, err
+	}
+	if _, err = fmt.Fscanf(r, "|%d\n", &nanos); err != nil {
+		return "", "", time.Time{}, err
+	}
+	if nanos == 0 {
+		return string(keyBuf), string(valBuf), time.Time{}, nil
+	}
+
+	return string(keyBuf), string(valBuf), time.Unix(0, nanos), nil
+}
+
+func TestCache_SaveLoadWithCodec(t *testing.T) {
+	cfunc := func(key string) (string, time.Time, error) {
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+	c1 := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: cfunc,
+		Codec:      fixedWidthCodec{},
+	})
+	for _, key := range []string{"KEY-0", "KEY-1"} {
+		if _, _, _, err := c1.Get(key); err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c1.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	c2 := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: func(string) (string, time.Time, error) {
+			t.Fatal("CreateFunc should not be called for an entry restored by Load")
+			return "", time.Time{}, nil
+		},
+		Codec: fixedWidthCodec{},
+	})
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	val, _, _, err := c2.Get("KEY-1")
+	if err != nil {
+		t.Fatalf("Get(KEY-1): %v", err)
+	}
+	if want := "VALUE(KEY-1)"; val != want {
+		t.Errorf("Get(KEY-1) = %q, want %q", val, want)
+	}
+	if stats := c2.Stats(); stats.Size != 2 {
+		t.Errorf("Size = %d, want 2", stats.Size)
+	}
+}
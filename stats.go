@@ -0,0 +1,124 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives notifications about Cache activity. Implementations must
+// be safe for concurrent use. Methods are invoked outside of Cache's
+// internal lock, so they may safely call back into the Cache, but may also
+// be called concurrently for different keys.
+type Observer[K comparable] interface {
+	// OnHit is called when Get finds a valid, already-created entry for key.
+	OnHit(key K)
+
+	// OnMiss is called when Get finds no usable entry for key and triggers
+	// creation.
+	OnMiss(key K)
+
+	// OnEvict is called when key is evicted from the cache to make room for
+	// a newly admitted key.
+	OnEvict(key K)
+
+	// OnExpire is called when key is removed because it reached its
+	// deadline.
+	OnExpire(key K)
+
+	// OnCreate is called after CreateFunc/CreateFuncCtx returns for key,
+	// with the time it took and the error it returned, if any.
+	OnCreate(key K, dur time.Duration, err error)
+}
+
+// Stats is a point-in-time snapshot of Cache activity counters, returned by
+// Cache.Stats.
+type Stats struct {
+	Hits           uint64
+	Misses         uint64
+	Evictions      uint64
+	Expirations    uint64
+	CreationErrors uint64
+	InFlight       int64
+	Size           int
+	Creation       LatencyStats
+}
+
+// LatencyStats summarizes the latency of CreateFunc/CreateFuncCtx calls.
+type LatencyStats struct {
+	Count uint64
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// Avg returns the mean creation latency, or 0 if Count is 0.
+func (l LatencyStats) Avg() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+
+	return l.Total / time.Duration(l.Count)
+}
+
+// statsCounters holds the live counters backing Cache.Stats. All fields are
+// accessed only through sync/atomic so they can be updated without taking
+// Cache.mu.
+type statsCounters struct {
+	hits, misses, evictions, expirations, creationErrors uint64
+	inFlight                                             int64
+	creationCount                                        uint64
+	creationTotal                                        int64 // nanoseconds
+	creationMin, creationMax                             int64 // nanoseconds
+}
+
+// recordCreation updates the creation latency counters for a single
+// CreateFunc/CreateFuncCtx call that took dur and returned err.
+func (s *statsCounters) recordCreation(dur time.Duration, err error) {
+	atomic.AddInt64(&s.inFlight, -1)
+	if err != nil {
+		atomic.AddUint64(&s.creationErrors, 1)
+	}
+	atomic.AddUint64(&s.creationCount, 1)
+	atomic.AddInt64(&s.creationTotal, int64(dur))
+
+	for {
+		cur := atomic.LoadInt64(&s.creationMin)
+		if cur != 0 && cur <= int64(dur) {
+			break
+		}
+		if atomic.CompareAndSwapInt64(&s.creationMin, cur, int64(dur)) {
+			break
+		}
+	}
+	for {
+		cur := atomic.LoadInt64(&s.creationMax)
+		if cur >= int64(dur) {
+			break
+		}
+		if atomic.CompareAndSwapInt64(&s.creationMax, cur, int64(dur)) {
+			break
+		}
+	}
+}
+
+func (s *statsCounters) snapshot(size int) Stats {
+	return Stats{
+		Hits:           atomic.LoadUint64(&s.hits),
+		Misses:         atomic.LoadUint64(&s.misses),
+		Evictions:      atomic.LoadUint64(&s.evictions),
+		Expirations:    atomic.LoadUint64(&s.expirations),
+		CreationErrors: atomic.LoadUint64(&s.creationErrors),
+		InFlight:       atomic.LoadInt64(&s.inFlight),
+		Size:           size,
+		Creation: LatencyStats{
+			Count: atomic.LoadUint64(&s.creationCount),
+			Total: time.Duration(atomic.LoadInt64(&s.creationTotal)),
+			Min:   time.Duration(atomic.LoadInt64(&s.creationMin)),
+			Max:   time.Duration(atomic.LoadInt64(&s.creationMax)),
+		},
+	}
+}
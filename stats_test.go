@@ -0,0 +1,195 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tunabay/go-cache"
+)
+
+// recordingObserver is a cache.Observer that records every callback it
+// receives, guarded by a mutex since Observer methods may be called
+// concurrently for different keys.
+type recordingObserver struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (o *recordingObserver) record(call string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, call)
+}
+
+func (o *recordingObserver) snapshot() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]string(nil), o.calls...)
+}
+
+func (o *recordingObserver) OnHit(key string)  { o.record("hit:" + key) }
+func (o *recordingObserver) OnMiss(key string) { o.record("miss:" + key) }
+func (o *recordingObserver) OnEvict(key string) {
+	o.record("evict:" + key)
+}
+func (o *recordingObserver) OnExpire(key string) {
+	o.record("expire:" + key)
+}
+func (o *recordingObserver) OnCreate(key string, _ time.Duration, err error) {
+	if err != nil {
+		o.record("create-err:" + key)
+		return
+	}
+	o.record("create:" + key)
+}
+
+// TestCache_ObserverCallbacks checks that Observer callbacks actually fire
+// for hits, misses, creations, evictions, and expirations.
+func TestCache_ObserverCallbacks(t *testing.T) {
+	obs := &recordingObserver{}
+	cfunc := func(key string) (string, time.Time, error) {
+		if key == "KEY-EXP" {
+			return fmt.Sprintf("VALUE(%s)", key), time.Now().Add(time.Millisecond), nil
+		}
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+	c := cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: cfunc,
+		MaxItems:   1,
+		Observer:   obs,
+	})
+
+	if _, _, _, err := c.Get("KEY-0"); err != nil { // miss + create
+		t.Fatalf("Get(KEY-0): %v", err)
+	}
+	if _, _, _, err := c.Get("KEY-0"); err != nil { // hit
+		t.Fatalf("Get(KEY-0): %v", err)
+	}
+	if _, _, _, err := c.Get("KEY-1"); err != nil { // miss + create; evicts KEY-0
+		t.Fatalf("Get(KEY-1): %v", err)
+	}
+	if _, _, _, err := c.Get("KEY-EXP"); err != nil { // miss + create; evicts KEY-1
+		t.Fatalf("Get(KEY-EXP): %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, _, _, err := c.Get("KEY-EXP"); err != nil { // finds it expired, then re-creates
+		t.Fatalf("Get(KEY-EXP): %v", err)
+	}
+
+	calls := obs.snapshot()
+	want := map[string]bool{
+		"miss:KEY-0":     false,
+		"create:KEY-0":   false,
+		"hit:KEY-0":      false,
+		"miss:KEY-1":     false,
+		"create:KEY-1":   false,
+		"evict:KEY-0":    false,
+		"miss:KEY-EXP":   false,
+		"create:KEY-EXP": false,
+		"evict:KEY-1":    false,
+		"expire:KEY-EXP": false,
+	}
+	for _, call := range calls {
+		if _, ok := want[call]; ok {
+			want[call] = true
+		}
+	}
+	for call, seen := range want {
+		if !seen {
+			t.Errorf("calls = %v, missing %q", calls, call)
+		}
+	}
+}
+
+// TestCache_ObserverCallbackIntoCache checks that Observer callbacks are
+// invoked outside Cache.mu, as documented, by calling back into the Cache
+// from inside a callback. If callbacks were invoked while holding the lock,
+// this would deadlock.
+func TestCache_ObserverCallbackIntoCache(t *testing.T) {
+	cfunc := func(key string) (string, time.Time, error) {
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+
+	var c *cache.Cache[string, string]
+	obs := &reentrantObserver{cfunc: func(key string) {
+		if key == "KEY-0" {
+			if _, _, _, err := c.Get("KEY-1"); err != nil {
+				t.Errorf("reentrant Get(KEY-1): %v", err)
+			}
+		}
+	}}
+	c = cache.NewWithConfig[string, string](&cache.Config[string, string]{
+		CreateFunc: cfunc,
+		Observer:   obs,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, _, _, err := c.Get("KEY-0"); err != nil {
+			t.Errorf("Get(KEY-0): %v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Get(KEY-0) did not return; observer callback likely deadlocked on Cache.mu")
+	}
+}
+
+// reentrantObserver calls back into the Cache from OnMiss to prove Observer
+// callbacks are invoked outside Cache.mu.
+type reentrantObserver struct {
+	cfunc func(key string)
+}
+
+func (o *reentrantObserver) OnHit(string)                          {}
+func (o *reentrantObserver) OnMiss(key string)                     { o.cfunc(key) }
+func (o *reentrantObserver) OnEvict(string)                        {}
+func (o *reentrantObserver) OnExpire(string)                       {}
+func (o *reentrantObserver) OnCreate(string, time.Duration, error) {}
+
+func TestCache_Stats(t *testing.T) {
+	cfunc := func(key string) (string, time.Time, error) {
+		if key == "KEY-ERR" {
+			return "", time.Time{}, fmt.Errorf("test error")
+		}
+		return fmt.Sprintf("VALUE(%s)", key), time.Time{}, nil
+	}
+	c := cache.New[string, string](cfunc)
+
+	if _, _, _, err := c.Get("KEY-0"); err != nil {
+		t.Fatalf("Get(KEY-0): %v", err)
+	}
+	if _, _, _, err := c.Get("KEY-0"); err != nil { // hit
+		t.Fatalf("Get(KEY-0): %v", err)
+	}
+	if _, _, _, err := c.Get("KEY-ERR"); err == nil {
+		t.Fatal("Get(KEY-ERR): want error, got nil")
+	}
+
+	stats := c.Stats()
+	if stats.Misses != 2 {
+		t.Errorf("Misses = %d, want 2", stats.Misses)
+	}
+	if stats.Hits != 1 {
+		t.Errorf("Hits = %d, want 1", stats.Hits)
+	}
+	if stats.CreationErrors != 1 {
+		t.Errorf("CreationErrors = %d, want 1", stats.CreationErrors)
+	}
+	if stats.Creation.Count != 2 {
+		t.Errorf("Creation.Count = %d, want 2", stats.Creation.Count)
+	}
+	if stats.Size != 1 {
+		t.Errorf("Size = %d, want 1", stats.Size)
+	}
+}
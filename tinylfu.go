@@ -0,0 +1,347 @@
+// Copyright (c) 2022 Hirotsuna Mizuno. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"hash/maphash"
+)
+
+// tlfuSegment identifies which of the three tinyLFUPolicy segments a node
+// currently lives in.
+type tlfuSegment int
+
+const (
+	segWindow tlfuSegment = iota
+	segProbation
+	segProtected
+)
+
+// tlfuNode is a node in one of tinyLFUPolicy's intrusive doubly linked
+// lists.
+type tlfuNode[K comparable] struct {
+	key        K
+	seg        tlfuSegment
+	prev, next *tlfuNode[K]
+}
+
+// tlfuList is a plain intrusive LRU list shared by all three segments.
+type tlfuList[K comparable] struct {
+	head, tail tlfuNode[K]
+	size       int
+}
+
+func newTlfuList[K comparable]() *tlfuList[K] {
+	l := &tlfuList[K]{}
+	l.head.next, l.tail.prev = &l.tail, &l.head
+
+	return l
+}
+
+func (l *tlfuList[K]) pushFront(n *tlfuNode[K]) {
+	n.next, l.head.next.prev = l.head.next, n
+	l.head.next, n.prev = n, &l.head
+	l.size++
+}
+
+func (l *tlfuList[K]) remove(n *tlfuNode[K]) {
+	n.prev.next, n.next.prev = n.next, n.prev
+	l.size--
+}
+
+func (l *tlfuList[K]) moveToFront(n *tlfuNode[K]) {
+	if l.head.next == n {
+		return
+	}
+	n.prev.next, n.next.prev = n.next, n.prev
+	n.next, l.head.next.prev = l.head.next, n
+	l.head.next, n.prev = n, &l.head
+}
+
+func (l *tlfuList[K]) back() *tlfuNode[K] {
+	if l.tail.prev == &l.head {
+		return nil
+	}
+
+	return l.tail.prev
+}
+
+// countMinSketch is a 4-bit-counter count-min sketch used to estimate how
+// often a key has recently been seen, without storing keys themselves. It
+// periodically halves all counters so that old activity decays over time.
+type countMinSketch struct {
+	width        uint64
+	rows         [4][]byte // two 4-bit counters packed per byte
+	seed         maphash.Seed
+	additions    uint64
+	maxAdditions uint64
+}
+
+func newCountMinSketch(width uint64) *countMinSketch {
+	if width < 16 {
+		width = 16
+	}
+	s := &countMinSketch{
+		width:        width,
+		seed:         maphash.MakeSeed(),
+		maxAdditions: width * 10,
+	}
+	bytesPerRow := (width + 1) / 2
+	for i := range s.rows {
+		s.rows[i] = make([]byte, bytesPerRow)
+	}
+
+	return s
+}
+
+func (s *countMinSketch) hash(v uint64) uint64 {
+	var h maphash.Hash
+	h.SetSeed(s.seed)
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = byte(v >> (8 * i))
+	}
+	_, _ = h.Write(buf[:])
+
+	return h.Sum64()
+}
+
+// indices returns the four counter positions, one per row, for keyHash.
+func (s *countMinSketch) indices(keyHash uint64) [4]uint64 {
+	var idx [4]uint64
+	for i := range idx {
+		mixed := s.hash(keyHash + uint64(i)*0x9e3779b97f4a7c15)
+		idx[i] = mixed % s.width
+	}
+
+	return idx
+}
+
+func getNibble(b byte, lo bool) uint8 {
+	if lo {
+		return uint8(b & 0x0f)
+	}
+
+	return uint8(b >> 4)
+}
+
+func setNibble(b byte, lo bool, v uint8) byte {
+	if lo {
+		return (b &^ 0x0f) | (v & 0x0f)
+	}
+
+	return (b &^ 0xf0) | (v << 4)
+}
+
+func (s *countMinSketch) get(row int, idx uint64) uint8 {
+	return getNibble(s.rows[row][idx/2], idx%2 == 0)
+}
+
+func (s *countMinSketch) set(row int, idx uint64, v uint8) {
+	s.rows[row][idx/2] = setNibble(s.rows[row][idx/2], idx%2 == 0, v)
+}
+
+// add increments the estimated frequency of keyHash, saturating at 15, and
+// triggers periodic halving of all counters once enough additions have
+// accumulated so that stale frequencies fade out.
+func (s *countMinSketch) add(keyHash uint64) {
+	for row, idx := range s.indices(keyHash) {
+		if v := s.get(row, idx); v < 15 {
+			s.set(row, idx, v+1)
+		}
+	}
+	s.additions++
+	if s.additions >= s.maxAdditions {
+		s.reset()
+	}
+}
+
+// estimate returns the minimum counter value across all rows for keyHash,
+// the standard count-min estimate of its frequency.
+func (s *countMinSketch) estimate(keyHash uint64) uint8 {
+	min := uint8(15)
+	for row, idx := range s.indices(keyHash) {
+		if v := s.get(row, idx); v < min {
+			min = v
+		}
+	}
+
+	return min
+}
+
+func (s *countMinSketch) reset() {
+	for row := range s.rows {
+		for i, b := range s.rows[row] {
+			s.rows[row][i] = setNibble(setNibble(b, true, getNibble(b, true)>>1), false, getNibble(b, false)>>1)
+		}
+	}
+	s.additions /= 2
+}
+
+// tinyLFUPolicy implements W-TinyLFU: a small window LRU admits new keys,
+// and keys evicted from the window contest for a place in a segmented main
+// cache (probationary and protected segments) based on a frequency estimate
+// from an aging count-min sketch. See Einziger, Friedman & Manes, "TinyLFU:
+// A Highly Efficient Cache Admission Policy" (2017).
+type tinyLFUPolicy[K comparable] struct {
+	maxItems     int
+	probationCap int
+	protectedCap int
+	nodes        map[K]*tlfuNode[K]
+	window       *tlfuList[K]
+	probation    *tlfuList[K]
+	protected    *tlfuList[K]
+	sketch       *countMinSketch
+}
+
+func newTinyLFUPolicy[K comparable](maxItems int) *tinyLFUPolicy[K] {
+	capacity := maxItems
+	if capacity == 0 {
+		capacity = 1024 // give the sketch and segments a concrete size to work with
+	}
+	mainCap := capacity - capacity/100
+	if mainCap < 1 {
+		mainCap = 1
+	}
+	protectedCap := mainCap * 4 / 5 // 80/20 protected/probationary split, per the TinyLFU paper
+	if protectedCap < 1 {
+		protectedCap = 1
+	}
+
+	return &tinyLFUPolicy[K]{
+		maxItems:     maxItems,
+		probationCap: mainCap - protectedCap,
+		protectedCap: protectedCap,
+		nodes:        make(map[K]*tlfuNode[K]),
+		window:       newTlfuList[K](),
+		probation:    newTlfuList[K](),
+		protected:    newTlfuList[K](),
+		sketch:       newCountMinSketch(uint64(capacity) * 10),
+	}
+}
+
+func (p *tinyLFUPolicy[K]) hashKey(key K) uint64 {
+	var h maphash.Hash
+	h.SetSeed(p.sketch.seed)
+	fmt.Fprintf(&h, "%v", key)
+
+	return h.Sum64()
+}
+
+func (p *tinyLFUPolicy[K]) removeNode(n *tlfuNode[K]) {
+	switch n.seg {
+	case segWindow:
+		p.window.remove(n)
+	case segProbation:
+		p.probation.remove(n)
+	case segProtected:
+		p.protected.remove(n)
+	}
+	delete(p.nodes, n.key)
+}
+
+func (p *tinyLFUPolicy[K]) Touch(key K) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	p.sketch.add(p.hashKey(key))
+
+	switch n.seg {
+	case segWindow:
+		p.window.moveToFront(n)
+
+	case segProtected:
+		p.protected.moveToFront(n)
+
+	case segProbation:
+		p.probation.remove(n)
+		n.seg = segProtected
+		p.protected.pushFront(n)
+		if p.protected.size > p.protectedCap {
+			demoted := p.protected.back()
+			p.protected.remove(demoted)
+			demoted.seg = segProbation
+			p.probation.pushFront(demoted)
+		}
+	}
+}
+
+func (p *tinyLFUPolicy[K]) Admit(key K) (K, bool) {
+	n := &tlfuNode[K]{key: key, seg: segWindow}
+	p.nodes[key] = n
+	p.window.pushFront(n)
+	p.sketch.add(p.hashKey(key))
+
+	if p.maxItems == 0 || len(p.nodes) <= p.maxItems {
+		var zero K
+		return zero, false
+	}
+
+	// Over capacity: the window's least recently used candidate contests
+	// for a place in the main cache against the coldest key outside the
+	// window, the probationary segment's tail (or the protected segment's
+	// tail, once probation holds only the candidate itself).
+	cand := p.window.back()
+	p.window.remove(cand)
+	cand.seg = segProbation
+	p.probation.pushFront(cand)
+
+	victim := p.probation.back()
+	if victim == cand && p.probation.size <= p.probationCap {
+		// Probation holds only the candidate and is within its capacity:
+		// contest the candidate against the protected segment's tail instead.
+		if pv := p.protected.back(); pv != nil {
+			victim = pv
+		} else if wv := p.window.back(); wv != nil {
+			// Nothing is established in main yet to contest against, and
+			// comparing the candidate against itself would always evict it,
+			// leaving main permanently empty. Admit it for free instead and
+			// make room by evicting the window's own new tail.
+			p.removeNode(wv)
+
+			return wv.key, true
+		} else {
+			// The candidate is the only node in the whole cache.
+			p.removeNode(cand)
+
+			return cand.key, true
+		}
+	}
+
+	// The candidate only displaces the incumbent victim if it is strictly
+	// more popular; ties favor the incumbent, to avoid admission thrashing
+	// between two equally (un)popular keys.
+	if p.sketch.estimate(p.hashKey(cand.key)) > p.sketch.estimate(p.hashKey(victim.key)) {
+		p.removeNode(victim)
+
+		return victim.key, true
+	}
+
+	p.removeNode(cand)
+
+	return cand.key, true
+}
+
+func (p *tinyLFUPolicy[K]) Remove(key K) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	p.removeNode(n)
+}
+
+// order returns protected keys first, then probationary, then window, since
+// that is the order in which this policy would give them up.
+func (p *tinyLFUPolicy[K]) order() []K {
+	keys := make([]K, 0, len(p.nodes))
+	for _, l := range [...]*tlfuList[K]{p.protected, p.probation, p.window} {
+		for n := l.head.next; n != &l.tail; n = n.next {
+			keys = append(keys, n.key)
+		}
+	}
+
+	return keys
+}